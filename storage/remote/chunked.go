@@ -0,0 +1,240 @@
+// Copyright 2017 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package remote
+
+import (
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"io"
+
+	"github.com/gogo/protobuf/proto"
+	"github.com/prometheus/prometheus/pkg/labels"
+	"github.com/prometheus/prometheus/prompb"
+	"github.com/prometheus/prometheus/storage"
+	"github.com/prometheus/prometheus/tsdb/chunkenc"
+)
+
+// castagnoliTable is the CRC32 polynomial used to checksum each frame, the
+// same one the remote read protocol's STREAMED_XOR_CHUNKS mode uses end to
+// end so a proxy can verify a frame without decoding the protobuf inside it.
+var castagnoliTable = crc32.MakeTable(crc32.Castagnoli)
+
+// chunkedReader reads the length-prefixed, checksummed frames of the
+// chunked remote read wire format off r: a uvarint frame length, that many
+// bytes of prompb.ChunkedReadResponse protobuf, then a 4-byte big-endian
+// CRC32 checksum of the protobuf bytes.
+type chunkedReader struct {
+	r   io.Reader
+	buf []byte
+}
+
+// newChunkedReader returns a chunkedReader reading frames from r.
+func newChunkedReader(r io.Reader) *chunkedReader {
+	return &chunkedReader{r: r}
+}
+
+// nextFrame reads and checksum-verifies the next frame's protobuf payload.
+// It returns io.EOF once r is exhausted between frames.
+func (r *chunkedReader) nextFrame() ([]byte, error) {
+	size, err := binary.ReadUvarint(&byteReader{r: r.r})
+	if err != nil {
+		return nil, err
+	}
+
+	if n := int(size) + crc32.Size; cap(r.buf) < n {
+		r.buf = make([]byte, n)
+	}
+	buf := r.buf[:int(size)+crc32.Size]
+	if _, err := io.ReadFull(r.r, buf); err != nil {
+		return nil, err
+	}
+
+	payload, checksum := buf[:size], buf[size:]
+	if want := binary.BigEndian.Uint32(checksum); crc32.Checksum(payload, castagnoliTable) != want {
+		return nil, errors.New("remote: chunked frame checksum mismatch")
+	}
+	return payload, nil
+}
+
+// byteReader adapts an io.Reader to io.ByteReader one byte at a time, which
+// is all binary.ReadUvarint needs for the frame-length prefix.
+type byteReader struct {
+	r   io.Reader
+	buf [1]byte
+}
+
+func (b *byteReader) ReadByte() (byte, error) {
+	if _, err := io.ReadFull(b.r, b.buf[:]); err != nil {
+		return 0, err
+	}
+	return b.buf[0], nil
+}
+
+// chunkedSeriesSet is a storage.SeriesSet that decodes a chunked remote read
+// response frame by frame, handing series to the caller as soon as their
+// frame arrives instead of buffering the whole response first.
+type chunkedSeriesSet struct {
+	body io.ReadCloser
+	dec  *chunkedReader
+
+	frame *prompb.ChunkedReadResponse
+	idx   int
+
+	cur storage.Series
+	err error
+}
+
+func newChunkedSeriesSet(body io.ReadCloser) *chunkedSeriesSet {
+	return &chunkedSeriesSet{body: body, dec: newChunkedReader(body), idx: -1}
+}
+
+// Next implements storage.SeriesSet.
+func (s *chunkedSeriesSet) Next() bool {
+	if s.err != nil {
+		return false
+	}
+	for {
+		if s.frame != nil {
+			s.idx++
+			if s.idx < len(s.frame.ChunkedSeries) {
+				s.cur = &chunkedSeries{raw: s.frame.ChunkedSeries[s.idx]}
+				return true
+			}
+		}
+
+		payload, err := s.dec.nextFrame()
+		if err == io.EOF {
+			s.err = s.body.Close()
+			return false
+		}
+		if err != nil {
+			s.err = err
+			s.body.Close()
+			return false
+		}
+
+		frame := &prompb.ChunkedReadResponse{}
+		if err := proto.Unmarshal(payload, frame); err != nil {
+			s.err = err
+			s.body.Close()
+			return false
+		}
+		s.frame = frame
+		s.idx = -1
+	}
+}
+
+// At implements storage.SeriesSet.
+func (s *chunkedSeriesSet) At() storage.Series {
+	return s.cur
+}
+
+// Err implements storage.SeriesSet.
+func (s *chunkedSeriesSet) Err() error {
+	return s.err
+}
+
+// chunkedSeries is a storage.Series backed by the raw TSDB chunks a
+// chunkedSeriesSet frame carried for one series, decoded lazily on Iterator.
+type chunkedSeries struct {
+	raw *prompb.ChunkedSeries
+}
+
+// Labels implements storage.Series.
+func (s *chunkedSeries) Labels() labels.Labels {
+	return labelProtosToLabels(s.raw.Labels)
+}
+
+// Iterator implements storage.Series, decoding each of the series' raw TSDB
+// chunks and iterating them back to back in the order the endpoint sent
+// them, which the remote read protocol guarantees is chronological.
+func (s *chunkedSeries) Iterator() storage.SeriesIterator {
+	return &chunkedSeriesIterator{chunks: s.raw.Chunks, idx: -1}
+}
+
+// chunkedSeriesIterator concatenates the per-chunk iterators of a
+// chunkedSeries' raw chunks into a single storage.SeriesIterator.
+type chunkedSeriesIterator struct {
+	chunks []prompb.Chunk
+	idx    int
+	it     chunkenc.Iterator
+	err    error
+}
+
+// Seek implements storage.SeriesIterator.
+func (it *chunkedSeriesIterator) Seek(t int64) bool {
+	if it.err != nil {
+		return false
+	}
+	for {
+		if it.it != nil {
+			if it.it.Seek(t) {
+				return true
+			}
+			if it.it.Err() != nil {
+				it.err = it.it.Err()
+				return false
+			}
+		}
+		if !it.nextChunk() {
+			return false
+		}
+	}
+}
+
+// At implements storage.SeriesIterator.
+func (it *chunkedSeriesIterator) At() (int64, float64) {
+	return it.it.At()
+}
+
+// Next implements storage.SeriesIterator.
+func (it *chunkedSeriesIterator) Next() bool {
+	if it.err != nil {
+		return false
+	}
+	for {
+		if it.it != nil && it.it.Next() {
+			return true
+		}
+		if it.it != nil && it.it.Err() != nil {
+			it.err = it.it.Err()
+			return false
+		}
+		if !it.nextChunk() {
+			return false
+		}
+	}
+}
+
+// nextChunk advances to the decoded iterator of the next raw chunk, and
+// reports whether one was available.
+func (it *chunkedSeriesIterator) nextChunk() bool {
+	it.idx++
+	if it.idx >= len(it.chunks) {
+		return false
+	}
+	c, err := chunkenc.FromData(chunkenc.Encoding(it.chunks[it.idx].Type), it.chunks[it.idx].Data)
+	if err != nil {
+		it.err = err
+		return false
+	}
+	it.it = c.Iterator(nil)
+	return true
+}
+
+// Err implements storage.SeriesIterator.
+func (it *chunkedSeriesIterator) Err() error {
+	return it.err
+}
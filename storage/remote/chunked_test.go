@@ -0,0 +1,111 @@
+// Copyright 2017 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package remote
+
+import (
+	"bytes"
+	"encoding/binary"
+	"hash/crc32"
+	"io"
+	"io/ioutil"
+	"testing"
+
+	"github.com/gogo/protobuf/proto"
+	"github.com/prometheus/prometheus/prompb"
+)
+
+// writeFrame appends a single chunked-read-response frame for msg onto buf,
+// in the same wire format chunkedReader.nextFrame expects.
+func writeFrame(buf *bytes.Buffer, msg *prompb.ChunkedReadResponse) {
+	payload, err := proto.Marshal(msg)
+	if err != nil {
+		panic(err)
+	}
+	lenBuf := make([]byte, binary.MaxVarintLen64)
+	n := binary.PutUvarint(lenBuf, uint64(len(payload)))
+	buf.Write(lenBuf[:n])
+	buf.Write(payload)
+	var checksum [4]byte
+	binary.BigEndian.PutUint32(checksum[:], crc32.Checksum(payload, castagnoliTable))
+	buf.Write(checksum[:])
+}
+
+func TestChunkedReader_RoundTrip(t *testing.T) {
+	want := &prompb.ChunkedReadResponse{
+		ChunkedSeries: []*prompb.ChunkedSeries{{
+			Labels: []prompb.Label{{Name: "__name__", Value: "up"}},
+		}},
+	}
+	var buf bytes.Buffer
+	writeFrame(&buf, want)
+
+	r := newChunkedReader(&buf)
+	payload, err := r.nextFrame()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var got prompb.ChunkedReadResponse
+	if err := proto.Unmarshal(payload, &got); err != nil {
+		t.Fatalf("failed to unmarshal decoded frame: %v", err)
+	}
+	if got.ChunkedSeries[0].Labels[0].Value != "up" {
+		t.Fatalf("got label value %q, want %q", got.ChunkedSeries[0].Labels[0].Value, "up")
+	}
+
+	if _, err := r.nextFrame(); err != io.EOF {
+		t.Fatalf("got err %v, want io.EOF", err)
+	}
+}
+
+func TestChunkedReader_DetectsCorruption(t *testing.T) {
+	var buf bytes.Buffer
+	writeFrame(&buf, &prompb.ChunkedReadResponse{})
+	corrupted := buf.Bytes()
+	corrupted[len(corrupted)-1] ^= 0xFF
+
+	r := newChunkedReader(bytes.NewReader(corrupted))
+	if _, err := r.nextFrame(); err == nil {
+		t.Fatalf("expected a checksum error, got nil")
+	}
+}
+
+func TestChunkedSeriesSet_DecodesFramesLazily(t *testing.T) {
+	var buf bytes.Buffer
+	writeFrame(&buf, &prompb.ChunkedReadResponse{
+		ChunkedSeries: []*prompb.ChunkedSeries{{
+			Labels: []prompb.Label{{Name: "__name__", Value: "up"}},
+		}},
+	})
+	writeFrame(&buf, &prompb.ChunkedReadResponse{
+		ChunkedSeries: []*prompb.ChunkedSeries{{
+			Labels: []prompb.Label{{Name: "__name__", Value: "down"}},
+		}},
+	})
+
+	set := newChunkedSeriesSet(ioutil.NopCloser(&buf))
+
+	var names []string
+	for set.Next() {
+		names = append(names, testLabelValue(set.At().Labels(), "__name__"))
+		if len(names) == 1 && buf.Len() == 0 {
+			t.Fatalf("the second frame was already read when the first series was returned")
+		}
+	}
+	if err := set.Err(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(names) != 2 || names[0] != "up" || names[1] != "down" {
+		t.Fatalf("got series %v, want [up down], one series decoded per frame in order", names)
+	}
+}
@@ -0,0 +1,149 @@
+// Copyright 2017 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package remote
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"time"
+
+	"github.com/gogo/protobuf/proto"
+	"github.com/golang/snappy"
+	"github.com/prometheus/prometheus/prompb"
+)
+
+// ClientConfig configures NewClient.
+type ClientConfig struct {
+	// URL is the remote read endpoint.
+	URL string
+	// Timeout bounds each Read/ReadChunked call. Zero means no client-side
+	// timeout beyond the context passed in.
+	Timeout time.Duration
+	// HTTPClient issues the requests. http.DefaultClient is used if nil.
+	HTTPClient *http.Client
+}
+
+// Client issues remote read requests against a single endpoint.
+type Client struct {
+	url        string
+	httpClient *http.Client
+	timeout    time.Duration
+}
+
+// NewClient returns a Client for conf.
+func NewClient(conf ClientConfig) (*Client, error) {
+	if conf.URL == "" {
+		return nil, errors.New("remote: client requires a URL")
+	}
+	httpClient := conf.HTTPClient
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	return &Client{url: conf.URL, httpClient: httpClient, timeout: conf.Timeout}, nil
+}
+
+// Read issues a sampled (non-chunked) remote read request for query and
+// returns the single result the protocol requires a one-query ReadRequest
+// to come back with.
+func (c *Client) Read(ctx context.Context, query *prompb.Query) (*prompb.QueryResult, error) {
+	resp, err := c.do(ctx, query, prompb.ReadRequest_SAMPLES)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	compressed, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	uncompressed, err := snappy.Decode(nil, compressed)
+	if err != nil {
+		return nil, err
+	}
+
+	var readResp prompb.ReadResponse
+	if err := proto.Unmarshal(uncompressed, &readResp); err != nil {
+		return nil, err
+	}
+	if len(readResp.Results) != 1 {
+		return nil, fmt.Errorf("remote: server returned %d results, want 1", len(readResp.Results))
+	}
+	return readResp.Results[0], nil
+}
+
+// ReadChunked issues a STREAMED_XOR_CHUNKS remote read request for query and
+// returns the raw response body: a sequence of length-prefixed, checksummed
+// prompb.ChunkedReadResponse frames (see chunkedReader), unlike Read's
+// single snappy-compressed protobuf message. The caller is responsible for
+// closing the returned body once it's done reading frames from it.
+func (c *Client) ReadChunked(ctx context.Context, query *prompb.Query) (io.ReadCloser, error) {
+	resp, err := c.do(ctx, query, prompb.ReadRequest_STREAMED_XOR_CHUNKS)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+// do issues the HTTP request backing Read/ReadChunked, asking the endpoint
+// for acceptedResponseType.
+func (c *Client) do(ctx context.Context, query *prompb.Query, acceptedResponseType prompb.ReadRequest_ResponseType) (*http.Response, error) {
+	req := &prompb.ReadRequest{
+		Queries:               []*prompb.Query{query},
+		AcceptedResponseTypes: []prompb.ReadRequest_ResponseType{acceptedResponseType},
+	}
+	data, err := proto.Marshal(req)
+	if err != nil {
+		return nil, err
+	}
+	compressed := snappy.Encode(nil, data)
+
+	// http.Client.Timeout keeps running while the caller reads the response
+	// body, which a context cancelled when do returns would cut short.
+	httpClient := c.httpClient
+	if c.timeout > 0 && (httpClient.Timeout == 0 || c.timeout < httpClient.Timeout) {
+		hc := *httpClient
+		hc.Timeout = c.timeout
+		httpClient = &hc
+	}
+
+	httpReq, err := http.NewRequest("POST", c.url, bytes.NewReader(compressed))
+	if err != nil {
+		return nil, err
+	}
+	httpReq = httpReq.WithContext(ctx)
+	httpReq.Header.Set("Content-Encoding", "snappy")
+	httpReq.Header.Set("Accept-Encoding", "snappy")
+	httpReq.Header.Set("Content-Type", "application/x-protobuf")
+	httpReq.Header.Set("X-Prometheus-Remote-Read-Version", "0.1.0")
+	if opts, ok := ReadOptionsFromContext(ctx); ok {
+		for k, v := range opts.Headers {
+			httpReq.Header.Set(k, v)
+		}
+	}
+
+	resp, err := httpClient.Do(httpReq)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode/100 != 2 {
+		resp.Body.Close()
+		return nil, fmt.Errorf("remote: server returned HTTP status %s", resp.Status)
+	}
+	return resp, nil
+}
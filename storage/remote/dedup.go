@@ -0,0 +1,161 @@
+// Copyright 2017 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package remote
+
+import (
+	"sort"
+
+	"github.com/prometheus/prometheus/pkg/labels"
+	"github.com/prometheus/prometheus/storage"
+)
+
+// newDedupMergeSeriesSet merges sets into a single, label-sorted
+// storage.SeriesSet. A series present in more than one input set is merged
+// into a single series, with sample-level deduplication: when two inputs
+// carry a sample at the same timestamp, the sample from the set that comes
+// later in sets wins.
+func newDedupMergeSeriesSet(sets []storage.SeriesSet) storage.SeriesSet {
+	bySeries := map[string]*dedupSeries{}
+	var order []string
+	var firstErr error
+
+	for _, set := range sets {
+		if set == nil {
+			continue
+		}
+		for set.Next() {
+			s := set.At()
+			key := s.Labels().String()
+			ds, ok := bySeries[key]
+			if !ok {
+				ds = &dedupSeries{lset: s.Labels()}
+				bySeries[key] = ds
+				order = append(order, key)
+			}
+			ds.series = append(ds.series, s)
+		}
+		// set.Next() returning false can mean either "exhausted" or "failed
+		// mid-iteration"; per the SeriesSet convention, Err() after Next()
+		// is false tells them apart.
+		if err := set.Err(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+
+	sort.Slice(order, func(i, j int) bool {
+		return labelsLess(bySeries[order[i]].lset, bySeries[order[j]].lset)
+	})
+	series := make([]storage.Series, 0, len(order))
+	for _, key := range order {
+		series = append(series, bySeries[key])
+	}
+	return &sliceSeriesSet{series: series, idx: -1, err: firstErr}
+}
+
+// dedupSeries is the merged view of a single logical series as returned by
+// one or more endpoints.
+type dedupSeries struct {
+	lset   labels.Labels
+	series []storage.Series
+}
+
+// Labels implements storage.Series.
+func (d *dedupSeries) Labels() labels.Labels {
+	return d.lset
+}
+
+// Iterator implements storage.Series, merging every underlying series'
+// samples into one timestamp-sorted, deduplicated stream.
+func (d *dedupSeries) Iterator() storage.SeriesIterator {
+	samples := map[int64]float64{}
+	for _, s := range d.series {
+		it := s.Iterator()
+		for it.Next() {
+			t, v := it.At()
+			// A series later in d.series wins ties at the same timestamp.
+			samples[t] = v
+		}
+		if it.Err() != nil {
+			return &errIterator{err: it.Err()}
+		}
+	}
+	ts := make([]int64, 0, len(samples))
+	for t := range samples {
+		ts = append(ts, t)
+	}
+	sort.Slice(ts, func(i, j int) bool { return ts[i] < ts[j] })
+	return &sliceSeriesIterator{timestamps: ts, values: samples, idx: -1}
+}
+
+// sliceSeriesSet is a storage.SeriesSet backed by a pre-materialized,
+// label-sorted slice, optionally carrying an error discovered while that
+// slice was being built (e.g. from one of several merged inputs).
+type sliceSeriesSet struct {
+	series []storage.Series
+	idx    int
+	err    error
+}
+
+func (s *sliceSeriesSet) Next() bool {
+	if s.err != nil {
+		return false
+	}
+	s.idx++
+	return s.idx < len(s.series)
+}
+
+func (s *sliceSeriesSet) At() storage.Series {
+	return s.series[s.idx]
+}
+
+func (s *sliceSeriesSet) Err() error {
+	return s.err
+}
+
+// sliceSeriesIterator is a storage.SeriesIterator over a pre-materialized,
+// deduplicated set of samples.
+type sliceSeriesIterator struct {
+	timestamps []int64
+	values     map[int64]float64
+	idx        int
+}
+
+func (it *sliceSeriesIterator) Seek(t int64) bool {
+	it.idx = sort.Search(len(it.timestamps), func(i int) bool { return it.timestamps[i] >= t })
+	return it.idx < len(it.timestamps)
+}
+
+func (it *sliceSeriesIterator) At() (int64, float64) {
+	t := it.timestamps[it.idx]
+	return t, it.values[t]
+}
+
+func (it *sliceSeriesIterator) Next() bool {
+	it.idx++
+	return it.idx < len(it.timestamps)
+}
+
+func (it *sliceSeriesIterator) Err() error {
+	return nil
+}
+
+// errIterator is a storage.SeriesIterator that immediately reports err.
+type errIterator struct {
+	err error
+}
+
+func (it *errIterator) Seek(int64) bool      { return false }
+func (it *errIterator) At() (int64, float64) { return 0, 0 }
+func (it *errIterator) Next() bool           { return false }
+func (it *errIterator) Err() error           { return it.err }
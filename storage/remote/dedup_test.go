@@ -0,0 +1,63 @@
+// Copyright 2017 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package remote
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/prometheus/prometheus/pkg/labels"
+	"github.com/prometheus/prometheus/storage"
+)
+
+func TestDedupMergeSeriesSet_TieBreakPrefersLaterSet(t *testing.T) {
+	lset := labels.Labels{{Name: "__name__", Value: "up"}}
+	early := &fakeSeriesSet{series: []storage.Series{
+		fakeSeries{lset: lset, samples: []fakeSample{{t: 10, v: 1}}},
+	}}
+	late := &fakeSeriesSet{series: []storage.Series{
+		fakeSeries{lset: lset, samples: []fakeSample{{t: 10, v: 2}}},
+	}}
+
+	set := newDedupMergeSeriesSet([]storage.SeriesSet{early, late})
+	if !set.Next() {
+		t.Fatalf("expected a merged series")
+	}
+	it := set.At().Iterator()
+	if !it.Next() {
+		t.Fatalf("expected a sample")
+	}
+	ts, v := it.At()
+	if ts != 10 || v != 2 {
+		t.Fatalf("got (%d, %v), want (10, 2): the later input set should win the timestamp tie", ts, v)
+	}
+	if set.Next() {
+		t.Fatalf("expected exactly one merged series")
+	}
+	if err := set.Err(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestDedupMergeSeriesSet_PropagatesInputError(t *testing.T) {
+	wantErr := errors.New("boom")
+	bad := &fakeSeriesSet{err: wantErr}
+
+	set := newDedupMergeSeriesSet([]storage.SeriesSet{bad})
+	for set.Next() {
+	}
+	if err := set.Err(); err != wantErr {
+		t.Fatalf("got err %v, want %v", err, wantErr)
+	}
+}
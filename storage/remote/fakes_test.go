@@ -0,0 +1,125 @@
+// Copyright 2017 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package remote
+
+import (
+	"github.com/prometheus/prometheus/pkg/labels"
+	"github.com/prometheus/prometheus/storage"
+)
+
+// fakeSample is a single (t, v) pair used to build fakeSeries in tests.
+type fakeSample struct {
+	t int64
+	v float64
+}
+
+// fakeSeries is a minimal storage.Series for tests that don't need a real
+// remote response to exercise.
+type fakeSeries struct {
+	lset    labels.Labels
+	samples []fakeSample
+}
+
+func (s fakeSeries) Labels() labels.Labels {
+	return s.lset
+}
+
+func (s fakeSeries) Iterator() storage.SeriesIterator {
+	return &fakeIterator{samples: s.samples, idx: -1}
+}
+
+type fakeIterator struct {
+	samples []fakeSample
+	idx     int
+}
+
+func (it *fakeIterator) Seek(t int64) bool {
+	if it.idx < 0 {
+		it.idx = 0
+	}
+	for it.idx < len(it.samples) && it.samples[it.idx].t < t {
+		it.idx++
+	}
+	return it.idx < len(it.samples)
+}
+
+func (it *fakeIterator) At() (int64, float64) {
+	s := it.samples[it.idx]
+	return s.t, s.v
+}
+
+func (it *fakeIterator) Next() bool {
+	it.idx++
+	return it.idx < len(it.samples)
+}
+
+func (it *fakeIterator) Err() error {
+	return nil
+}
+
+// fakeSeriesSet is a minimal storage.SeriesSet for tests, optionally
+// failing once its series are exhausted so tests can exercise the
+// "Next() returns false, then check Err()" convention.
+type fakeSeriesSet struct {
+	series []storage.Series
+	idx    int
+	began  bool
+	err    error
+}
+
+func (s *fakeSeriesSet) Next() bool {
+	if !s.began {
+		s.began = true
+	} else {
+		s.idx++
+	}
+	return s.idx < len(s.series)
+}
+
+func (s *fakeSeriesSet) At() storage.Series {
+	return s.series[s.idx]
+}
+
+func (s *fakeSeriesSet) Err() error {
+	return s.err
+}
+
+// fakeQuerier is a minimal storage.Querier for tests.
+type fakeQuerier struct {
+	selectFn func(matchers ...*labels.Matcher) storage.SeriesSet
+	closed   bool
+}
+
+func (q *fakeQuerier) Select(matchers ...*labels.Matcher) storage.SeriesSet {
+	return q.selectFn(matchers...)
+}
+
+func (q *fakeQuerier) LabelValues(name string) ([]string, error) {
+	return nil, nil
+}
+
+func (q *fakeQuerier) Close() error {
+	q.closed = true
+	return nil
+}
+
+// testLabelValue returns the value of name on ls, or "" if absent.
+func testLabelValue(ls labels.Labels, name string) string {
+	for _, l := range ls {
+		if l.Name == name {
+			return l.Value
+		}
+	}
+	return ""
+}
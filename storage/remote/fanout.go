@@ -0,0 +1,230 @@
+// Copyright 2017 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package remote
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/pkg/labels"
+	"github.com/prometheus/prometheus/storage"
+)
+
+// PartialResponseStrategy controls how a fanoutQuerier behaves when one of
+// its endpoints fails.
+type PartialResponseStrategy int
+
+const (
+	// PartialResponseFail fails the whole Select if any endpoint returns an
+	// error. Select still waits for every endpoint's request to finish first.
+	PartialResponseFail PartialResponseStrategy = iota
+	// PartialResponseWarn returns the series collected from the endpoints
+	// that succeeded and surfaces the others' errors as warnings instead of
+	// failing the query outright.
+	PartialResponseWarn
+)
+
+// FanoutClient pairs a remote read Client with the external labels it was
+// configured with, so FanoutQueryableClient can route a query away from
+// endpoints that can never answer it.
+type FanoutClient struct {
+	Client         *Client
+	ExternalLabels model.LabelSet
+}
+
+// FanoutOptions configures FanoutQueryableClient.
+type FanoutOptions struct {
+	// Timeout bounds each individual endpoint request. Zero means no
+	// per-endpoint timeout beyond the context passed to Select.
+	Timeout time.Duration
+	// MaxConcurrentRequests caps how many endpoints are queried in parallel
+	// for a single Select call. Zero or less means unlimited.
+	MaxConcurrentRequests int
+	// PartialResponse controls what happens when some, but not all,
+	// endpoints fail.
+	PartialResponse PartialResponseStrategy
+	// MatchEndpoint, if set, is consulted once per endpoint before it is
+	// queried; the endpoint is skipped if it returns false. Used to keep a
+	// query for cluster="A" from being fanned out to an endpoint whose
+	// external labels pin cluster="B".
+	MatchEndpoint func(externalLabels model.LabelSet, matchers []*labels.Matcher) bool
+}
+
+// FanoutQueryableClient returns a storage.Queryable which queries all given
+// clients in parallel from a single Select call and merges the results,
+// deduplicating samples at colliding timestamps in favour of the newest
+// sample seen -- the same policy Thanos applies across replicated stores.
+func FanoutQueryableClient(clients []FanoutClient, opts FanoutOptions) storage.Queryable {
+	return storage.QuerierFunc(func(ctx context.Context, mint, maxt int64) (storage.Querier, error) {
+		return &fanoutQuerier{
+			ctx:     ctx,
+			mint:    mint,
+			maxt:    maxt,
+			clients: clients,
+			opts:    opts,
+		}, nil
+	})
+}
+
+// fanoutQuerier is a storage.Querier that fans a single Select out across
+// multiple remote read endpoints.
+type fanoutQuerier struct {
+	ctx        context.Context
+	mint, maxt int64
+	clients    []FanoutClient
+	opts       FanoutOptions
+}
+
+type fanoutResult struct {
+	endpoint *Client
+	set      storage.SeriesSet
+	err      error
+}
+
+// Select implements storage.Querier. It queries every endpoint whose
+// external labels are compatible with matchers in parallel, then merges the
+// resulting series sets with sample-level deduplication.
+func (q *fanoutQuerier) Select(matchers ...*labels.Matcher) storage.SeriesSet {
+	targets := q.targets(matchers)
+	if len(targets) == 0 {
+		return storage.NoopSeriesSet()
+	}
+
+	results := q.selectAll(targets, matchers)
+	return mergeFanoutResults(results, q.opts.PartialResponse)
+}
+
+// mergeFanoutResults applies policy to results and merges whatever is left
+// into a single storage.SeriesSet. Split out from Select so the merge/
+// partial-response logic can be tested without issuing real endpoint
+// requests.
+func mergeFanoutResults(results []fanoutResult, policy PartialResponseStrategy) storage.SeriesSet {
+	var (
+		sets     []storage.SeriesSet
+		warnings []error
+	)
+	for _, r := range results {
+		if r.err != nil {
+			if policy == PartialResponseFail {
+				return errSeriesSet{err: r.err}
+			}
+			warnings = append(warnings, r.err)
+			continue
+		}
+		sets = append(sets, r.set)
+	}
+
+	merged := newDedupMergeSeriesSet(sets)
+	if len(warnings) > 0 {
+		return &warnSeriesSet{SeriesSet: merged, warnings: warnings}
+	}
+	return merged
+}
+
+// targets returns the endpoints eligible to answer matchers.
+func (q *fanoutQuerier) targets(matchers []*labels.Matcher) []FanoutClient {
+	if q.opts.MatchEndpoint == nil {
+		return q.clients
+	}
+	targets := make([]FanoutClient, 0, len(q.clients))
+	for _, c := range q.clients {
+		if q.opts.MatchEndpoint(c.ExternalLabels, matchers) {
+			targets = append(targets, c)
+		}
+	}
+	return targets
+}
+
+// selectAll runs a series request against every target in parallel, capped
+// at opts.MaxConcurrentRequests.
+func (q *fanoutQuerier) selectAll(targets []FanoutClient, matchers []*labels.Matcher) []fanoutResult {
+	results := make([]fanoutResult, len(targets))
+
+	limit := q.opts.MaxConcurrentRequests
+	if limit <= 0 || limit > len(targets) {
+		limit = len(targets)
+	}
+	sem := make(chan struct{}, limit)
+
+	var wg sync.WaitGroup
+	for i, c := range targets {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int, c FanoutClient) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			results[i] = q.selectOne(c, matchers)
+		}(i, c)
+	}
+	wg.Wait()
+	return results
+}
+
+func (q *fanoutQuerier) selectOne(c FanoutClient, matchers []*labels.Matcher) fanoutResult {
+	ctx := q.ctx
+	if q.opts.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, q.opts.Timeout)
+		defer cancel()
+	}
+
+	query, err := ToQuery(q.mint, q.maxt, matchers)
+	if err != nil {
+		return fanoutResult{endpoint: c.Client, err: err}
+	}
+	res, err := c.Client.Read(ctx, query)
+	if err != nil {
+		return fanoutResult{endpoint: c.Client, err: err}
+	}
+	// newDedupMergeSeriesSet keys cross-endpoint dedup on the series'
+	// Labels().String(), which is order-sensitive: sort each endpoint's
+	// result the same way querier.selectSeriesSet does before merging, or
+	// two endpoints returning the same logical series with labels in a
+	// different order silently fail to dedup against each other.
+	return fanoutResult{endpoint: c.Client, set: sortSeriesSet(FromQueryResult(res))}
+}
+
+// LabelValues implements storage.Querier by merging the values reported by
+// every endpoint.
+func (q *fanoutQuerier) LabelValues(name string) ([]string, error) {
+	set := q.Select(matchEverything)
+	return labelValuesFromSeriesSet(set, name)
+}
+
+// LabelNames implements querierLabelNamer by merging the label names reported
+// by every endpoint.
+func (q *fanoutQuerier) LabelNames() ([]string, error) {
+	set := q.Select(matchEverything)
+	return labelNamesFromSeriesSet(set)
+}
+
+// Close implements storage.Querier and is a noop: endpoint requests are
+// short-lived HTTP round trips with nothing left open once Select returns.
+func (q *fanoutQuerier) Close() error {
+	return nil
+}
+
+// warnSeriesSet decorates a storage.SeriesSet with errors collected from
+// endpoints that failed under PartialResponseWarn.
+type warnSeriesSet struct {
+	storage.SeriesSet
+	warnings []error
+}
+
+// Warnings returns the per-endpoint errors collected while building the set.
+func (w *warnSeriesSet) Warnings() []error {
+	return w.warnings
+}
@@ -0,0 +1,179 @@
+// Copyright 2017 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package remote
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gogo/protobuf/proto"
+	"github.com/golang/snappy"
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/pkg/labels"
+	"github.com/prometheus/prometheus/prompb"
+	"github.com/prometheus/prometheus/storage"
+)
+
+func TestMergeFanoutResults_FailFastSurfacesTheError(t *testing.T) {
+	wantErr := errors.New("endpoint down")
+	lset := labels.Labels{{Name: "__name__", Value: "up"}}
+	results := []fanoutResult{
+		{set: &fakeSeriesSet{series: []storage.Series{fakeSeries{lset: lset}}}},
+		{err: wantErr},
+	}
+
+	set := mergeFanoutResults(results, PartialResponseFail)
+	set.Next()
+	if err := set.Err(); err != wantErr {
+		t.Fatalf("got err %v, want %v", err, wantErr)
+	}
+}
+
+func TestMergeFanoutResults_WarnKeepsPartialDataAndWarnings(t *testing.T) {
+	wantErr := errors.New("endpoint down")
+	lset := labels.Labels{{Name: "__name__", Value: "up"}}
+	results := []fanoutResult{
+		{set: &fakeSeriesSet{series: []storage.Series{fakeSeries{lset: lset, samples: []fakeSample{{t: 1, v: 1}}}}}},
+		{err: wantErr},
+	}
+
+	set := mergeFanoutResults(results, PartialResponseWarn)
+	ws, ok := set.(*warnSeriesSet)
+	if !ok {
+		t.Fatalf("expected a *warnSeriesSet, got %T", set)
+	}
+	if warnings := ws.Warnings(); len(warnings) != 1 || warnings[0] != wantErr {
+		t.Fatalf("got warnings %v, want [%v]", warnings, wantErr)
+	}
+	if !set.Next() {
+		t.Fatalf("expected the successful endpoint's series to still be returned")
+	}
+}
+
+func TestFanoutQuerier_Targets_MatchEndpointFiltersByExternalLabels(t *testing.T) {
+	clusterA := FanoutClient{ExternalLabels: model.LabelSet{"cluster": "a"}}
+	clusterB := FanoutClient{ExternalLabels: model.LabelSet{"cluster": "b"}}
+
+	q := &fanoutQuerier{
+		clients: []FanoutClient{clusterA, clusterB},
+		opts: FanoutOptions{
+			MatchEndpoint: func(externalLabels model.LabelSet, matchers []*labels.Matcher) bool {
+				return externalLabels["cluster"] == "a"
+			},
+		},
+	}
+
+	targets := q.targets(nil)
+	if len(targets) != 1 || targets[0].ExternalLabels["cluster"] != "a" {
+		t.Fatalf("got targets %v, want only the cluster=a endpoint", targets)
+	}
+}
+
+// readResponseHandler returns an http.HandlerFunc that answers every
+// request with a valid, single-result remote read response, after calling
+// before (if non-nil) so tests can observe/delay the request first.
+func readResponseHandler(before func(r *http.Request)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if before != nil {
+			before(r)
+		}
+		data, err := proto.Marshal(&prompb.ReadResponse{Results: []*prompb.QueryResult{{}}})
+		if err != nil {
+			panic(err)
+		}
+		w.Write(snappy.Encode(nil, data))
+	}
+}
+
+func TestFanoutQuerier_SelectAll_CapsMaxConcurrentRequests(t *testing.T) {
+	const numTargets, limit = 4, 2
+
+	var current, maxSeen int32
+	release := make(chan struct{})
+	srv := httptest.NewServer(readResponseHandler(func(r *http.Request) {
+		n := atomic.AddInt32(&current, 1)
+		for {
+			seen := atomic.LoadInt32(&maxSeen)
+			if n <= seen || atomic.CompareAndSwapInt32(&maxSeen, seen, n) {
+				break
+			}
+		}
+		<-release
+		atomic.AddInt32(&current, -1)
+	}))
+	defer srv.Close()
+
+	clients := make([]FanoutClient, numTargets)
+	for i := range clients {
+		c, err := NewClient(ClientConfig{URL: srv.URL})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		clients[i] = FanoutClient{Client: c}
+	}
+
+	q := &fanoutQuerier{ctx: context.Background(), clients: clients, opts: FanoutOptions{MaxConcurrentRequests: limit}}
+
+	done := make(chan []fanoutResult, 1)
+	go func() { done <- q.selectAll(q.targets(nil), nil) }()
+
+	deadline := time.After(2 * time.Second)
+	for atomic.LoadInt32(&current) < limit {
+		select {
+		case <-deadline:
+			t.Fatalf("timed out waiting for %d concurrent requests to arrive", limit)
+		case <-time.After(5 * time.Millisecond):
+		}
+	}
+	if got := atomic.LoadInt32(&current); got > limit {
+		t.Fatalf("got %d concurrent requests in flight, want at most %d", got, limit)
+	}
+
+	close(release)
+	results := <-done
+	if len(results) != numTargets {
+		t.Fatalf("got %d results, want %d", len(results), numTargets)
+	}
+	if got := atomic.LoadInt32(&maxSeen); got > limit {
+		t.Fatalf("got %d max concurrent requests, want at most %d", got, limit)
+	}
+}
+
+func TestFanoutQuerier_SelectOne_TimeoutCancelsSlowEndpoint(t *testing.T) {
+	block := make(chan struct{})
+	defer close(block)
+	srv := httptest.NewServer(readResponseHandler(func(r *http.Request) {
+		select {
+		case <-block:
+		case <-r.Context().Done():
+		}
+	}))
+	defer srv.Close()
+
+	c, err := NewClient(ClientConfig{URL: srv.URL})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	q := &fanoutQuerier{ctx: context.Background(), opts: FanoutOptions{Timeout: 20 * time.Millisecond}}
+	result := q.selectOne(FanoutClient{Client: c}, nil)
+	if result.err == nil {
+		t.Fatalf("expected the endpoint exceeding opts.Timeout to fail")
+	}
+}
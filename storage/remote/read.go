@@ -15,23 +15,30 @@ package remote
 
 import (
 	"context"
+	"sort"
 
 	"github.com/prometheus/common/model"
 	"github.com/prometheus/prometheus/pkg/labels"
 	"github.com/prometheus/prometheus/storage"
 )
 
+// matchEverything matches any series with a metric name, used to synthesize
+// a metadata request out of a series request when the remote read protocol
+// offers no dedicated endpoint for label names/values.
+var matchEverything *labels.Matcher
+
+func init() {
+	m, err := labels.NewMatcher(labels.MatchRegexp, labels.MetricName, ".+")
+	if err != nil {
+		panic(err)
+	}
+	matchEverything = m
+}
+
 // QueryableClient returns a storage.Queryable which queries the given
 // Client to select series sets.
 func QueryableClient(c *Client) storage.Queryable {
-	return storage.QuerierFunc(func(ctx context.Context, mint, maxt int64) (storage.Querier, error) {
-		return &querier{
-			ctx:    ctx,
-			mint:   mint,
-			maxt:   maxt,
-			client: c,
-		}, nil
-	})
+	return QueryableClientWithOptions(c, QueryableClientOptions{})
 }
 
 // querier is an adapter to make a Client usable as a storage.Querier.
@@ -39,28 +46,120 @@ type querier struct {
 	ctx        context.Context
 	mint, maxt int64
 	client     *Client
+	streaming  bool
 }
 
 // Select implements storage.Querier and uses the given matchers to read series
 // sets from the Client.
 func (q *querier) Select(matchers ...*labels.Matcher) storage.SeriesSet {
-	query, err := ToQuery(q.mint, q.maxt, matchers)
+	set, err := q.selectSeriesSet(matchers...)
 	if err != nil {
 		return errSeriesSet{err: err}
 	}
+	return set
+}
+
+// selectSeriesSet issues a series request against the Client. Unless
+// q.streaming is set, the result is buffered and returned as a label-sorted
+// storage.SeriesSet; see StreamingSelect for the streaming path.
+func (q *querier) selectSeriesSet(matchers ...*labels.Matcher) (storage.SeriesSet, error) {
+	query, err := ToQuery(q.mint, q.maxt, matchers)
+	if err != nil {
+		return nil, err
+	}
+
+	if q.streaming {
+		return StreamingSelect(q.ctx, q.client, query)
+	}
 
 	res, err := q.client.Read(q.ctx, query)
 	if err != nil {
-		return errSeriesSet{err: err}
+		return nil, err
 	}
 
-	return FromQueryResult(res)
+	return sortSeriesSet(FromQueryResult(res)), nil
 }
 
-// LabelValues implements storage.Querier and is a noop.
+// LabelValues implements storage.Querier. The remote read protocol has no
+// dedicated metadata endpoint, so the values are projected out of a series
+// request that matches everything in the queried time range.
 func (q *querier) LabelValues(name string) ([]string, error) {
-	// TODO implement?
-	return nil, nil
+	set, err := q.selectSeriesSet(matchEverything)
+	if err != nil {
+		return nil, err
+	}
+	return labelValuesFromSeriesSet(set, name)
+}
+
+// LabelNames implements querierLabelNamer in the same fashion as LabelValues,
+// projecting the label names found on every series in the queried time range.
+func (q *querier) LabelNames() ([]string, error) {
+	set, err := q.selectSeriesSet(matchEverything)
+	if err != nil {
+		return nil, err
+	}
+	return labelNamesFromSeriesSet(set)
+}
+
+// labelValuesFromSeriesSet returns the sorted, deduplicated set of values
+// the named label takes on across set.
+func labelValuesFromSeriesSet(set storage.SeriesSet, name string) ([]string, error) {
+	values := map[string]struct{}{}
+	for set.Next() {
+		for _, l := range set.At().Labels() {
+			if l.Name == name {
+				values[l.Value] = struct{}{}
+			}
+		}
+	}
+	if set.Err() != nil {
+		return nil, set.Err()
+	}
+	return sortedKeys(values), nil
+}
+
+// labelNamesFromSeriesSet returns the sorted, deduplicated set of label
+// names found across set.
+func labelNamesFromSeriesSet(set storage.SeriesSet) ([]string, error) {
+	names := map[string]struct{}{}
+	for set.Next() {
+		for _, l := range set.At().Labels() {
+			names[l.Name] = struct{}{}
+		}
+	}
+	if set.Err() != nil {
+		return nil, set.Err()
+	}
+	return sortedKeys(names), nil
+}
+
+// querierLabelNamer is implemented by storage.Querier values that can
+// answer LabelNames. It isn't declared on storage.Querier itself at this
+// vintage of the interface, unlike LabelValues, so code that
+// only holds a storage.Querier (an interface value, as opposed to one of
+// this package's own concrete querier types) must check for it rather than
+// calling LabelNames() directly.
+type querierLabelNamer interface {
+	LabelNames() ([]string, error)
+}
+
+// querierLabelNames calls q.LabelNames() if q implements it, and returns
+// (nil, nil) otherwise.
+func querierLabelNames(q storage.Querier) ([]string, error) {
+	lq, ok := q.(querierLabelNamer)
+	if !ok {
+		return nil, nil
+	}
+	return lq.LabelNames()
+}
+
+func sortedKeys(m map[string]struct{}) []string {
+	out := make([]string, 0, len(m))
+	for k := range m {
+		out = append(out, k)
+	}
+	sort.Strings(out)
+	return out
 }
 
 // Close implements storage.Querier and is a noop.
@@ -93,13 +192,43 @@ type externalLabelsQuerier struct {
 // removed from the returned series sets.
 func (q externalLabelsQuerier) Select(matchers ...*labels.Matcher) storage.SeriesSet {
 	m, added := addExternalLabels(q.externalLabels, matchers)
-	s := q.Select(m...)
+	s := q.Querier.Select(m...)
 	return newSeriesSetFilter(s, added)
 }
 
+// LabelValues implements storage.Querier. If name is one of the configured
+// external labels, its value is only reported if at least one series
+// matches on the remote side -- otherwise we'd be claiming data exists
+// (e.g. up{foo="bar"} when foo is an external label) for a series that
+// doesn't actually exist remotely.
+func (q externalLabelsQuerier) LabelValues(name string) ([]string, error) {
+	v, ok := q.externalLabels[model.LabelName(name)]
+	if !ok {
+		return q.Querier.LabelValues(name)
+	}
+	m, _ := addExternalLabels(q.externalLabels, []*labels.Matcher{matchEverything})
+	set := q.Querier.Select(m...)
+	if set.Next() {
+		return []string{string(v)}, nil
+	}
+	return nil, set.Err()
+}
+
+// LabelNames implements querierLabelNamer, pushing the configured external
+// labels down into the request the same way Select does, and stripping them
+// back out of the result so they don't shadow the series' own label names.
+func (q externalLabelsQuerier) LabelNames() ([]string, error) {
+	m, added := addExternalLabels(q.externalLabels, []*labels.Matcher{matchEverything})
+	set := newSeriesSetFilter(q.Querier.Select(m...), added)
+	return labelNamesFromSeriesSet(set)
+}
+
 // PreferLocalStorageFilter returns a QuerierFunc which creates a NoopQuerier if
 // requested timeframe can be answered completely by the local TSDB, and reduces
-// maxt if the timeframe can be partially answered by TSDB.
+// maxt if the timeframe can be partially answered by TSDB. It never queries the
+// local TSDB's own portion of a straddling range, so callers wanting the local
+// and remote samples merged in a single response should use
+// SplitLocalRemoteQueryable instead.
 func PreferLocalStorageFilter(next storage.Queryable, cb startTimeCallback) storage.Queryable {
 	return storage.QuerierFunc(func(ctx context.Context, mint, maxt int64) (storage.Querier, error) {
 		localStartTime, err := cb()
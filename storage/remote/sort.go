@@ -0,0 +1,66 @@
+// Copyright 2017 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package remote
+
+import (
+	"sort"
+
+	"github.com/prometheus/prometheus/pkg/labels"
+	"github.com/prometheus/prometheus/storage"
+)
+
+// sortSeriesSet materializes set and returns it as a storage.SeriesSet whose
+// series are sorted by their (re-sorted) labels. Downstream mergers such as
+// newDedupMergeSeriesSet and the PromQL engine's own merge assume
+// label-sorted input; a remote read response makes no such guarantee, so
+// this must run between decoding the response and handing it to a caller.
+func sortSeriesSet(set storage.SeriesSet) storage.SeriesSet {
+	var series []storage.Series
+	for set.Next() {
+		series = append(series, sortedLabelsSeries{set.At()})
+	}
+	if set.Err() != nil {
+		return errSeriesSet{err: set.Err()}
+	}
+	sort.Slice(series, func(i, j int) bool {
+		return labelsLess(series[i].Labels(), series[j].Labels())
+	})
+	return &sliceSeriesSet{series: series, idx: -1}
+}
+
+// sortedLabelsSeries wraps a storage.Series to guarantee its own Labels()
+// come back sorted, independent of how the remote endpoint ordered them.
+type sortedLabelsSeries struct {
+	storage.Series
+}
+
+func (s sortedLabelsSeries) Labels() labels.Labels {
+	ls := append(labels.Labels(nil), s.Series.Labels()...)
+	sort.Sort(ls)
+	return ls
+}
+
+// labelsLess reports whether a sorts before b, comparing label name/value
+// pairs in order.
+func labelsLess(a, b labels.Labels) bool {
+	for i := 0; i < len(a) && i < len(b); i++ {
+		if a[i].Name != b[i].Name {
+			return a[i].Name < b[i].Name
+		}
+		if a[i].Value != b[i].Value {
+			return a[i].Value < b[i].Value
+		}
+	}
+	return len(a) < len(b)
+}
@@ -0,0 +1,165 @@
+// Copyright 2017 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package remote
+
+import (
+	"context"
+
+	"github.com/prometheus/prometheus/pkg/labels"
+	"github.com/prometheus/prometheus/storage"
+)
+
+// SplitOptions configures SplitLocalRemoteQueryable.
+type SplitOptions struct {
+	// PartialResponse controls what happens when one side of a straddling
+	// query fails: fail the whole Select, or return what the other side
+	// found and surface the failure as a warning.
+	PartialResponse PartialResponseStrategy
+}
+
+// SplitLocalRemoteQueryable returns a storage.Queryable which, for a query
+// range that straddles localStartTime (as reported by cb), queries both
+// local and remote in the same call -- remote for [mint, localStartTime]
+// and local for [localStartTime, maxt], so both sides cover the boundary
+// timestamp and the local sample wins there -- and merges the results. This
+// differs from PreferLocalStorageFilter, which truncates maxt and leaves
+// the local portion of a straddling range unqueried.
+func SplitLocalRemoteQueryable(local, remote storage.Queryable, cb startTimeCallback, opts SplitOptions) storage.Queryable {
+	return storage.QuerierFunc(func(ctx context.Context, mint, maxt int64) (storage.Querier, error) {
+		localStartTime, err := cb()
+		if err != nil {
+			return nil, err
+		}
+
+		switch {
+		case maxt <= localStartTime:
+			return remote.Querier(ctx, mint, maxt)
+		case mint > localStartTime:
+			return local.Querier(ctx, mint, maxt)
+		default:
+			remoteQ, err := remote.Querier(ctx, mint, localStartTime)
+			if err != nil {
+				return nil, err
+			}
+			localQ, err := local.Querier(ctx, localStartTime, maxt)
+			if err != nil {
+				remoteQ.Close()
+				return nil, err
+			}
+			return &splitQuerier{local: localQ, remote: remoteQ, opts: opts}, nil
+		}
+	})
+}
+
+// splitQuerier merges a local and a remote storage.Querier covering
+// adjoining time ranges into a single view.
+type splitQuerier struct {
+	local, remote storage.Querier
+	opts          SplitOptions
+}
+
+// Select queries both sides and merges the results, preferring the local
+// sample at the boundary timestamp if both sides happen to carry one.
+func (q *splitQuerier) Select(matchers ...*labels.Matcher) storage.SeriesSet {
+	remoteSet, remoteErr := q.selectSide(q.remote, matchers)
+	if remoteErr != nil && q.opts.PartialResponse == PartialResponseFail {
+		return errSeriesSet{err: remoteErr}
+	}
+	localSet, localErr := q.selectSide(q.local, matchers)
+	if localErr != nil && q.opts.PartialResponse == PartialResponseFail {
+		return errSeriesSet{err: localErr}
+	}
+
+	// remoteSet is listed first so localSet wins sample-level ties at the
+	// boundary timestamp.
+	merged := newDedupMergeSeriesSet([]storage.SeriesSet{remoteSet, localSet})
+
+	var warnings []error
+	if remoteErr != nil {
+		warnings = append(warnings, remoteErr)
+	}
+	if localErr != nil {
+		warnings = append(warnings, localErr)
+	}
+	if len(warnings) > 0 {
+		return &warnSeriesSet{SeriesSet: merged, warnings: warnings}
+	}
+	return merged
+}
+
+func (q *splitQuerier) selectSide(side storage.Querier, matchers []*labels.Matcher) (storage.SeriesSet, error) {
+	if side == nil {
+		return nil, nil
+	}
+	set := side.Select(matchers...)
+	if set.Err() != nil {
+		return nil, set.Err()
+	}
+	return set, nil
+}
+
+// LabelValues implements storage.Querier by merging the values reported by
+// both sides.
+func (q *splitQuerier) LabelValues(name string) ([]string, error) {
+	remoteValues, err := q.remote.LabelValues(name)
+	if err != nil && q.opts.PartialResponse == PartialResponseFail {
+		return nil, err
+	}
+	localValues, err := q.local.LabelValues(name)
+	if err != nil && q.opts.PartialResponse == PartialResponseFail {
+		return nil, err
+	}
+	seen := map[string]struct{}{}
+	for _, v := range remoteValues {
+		seen[v] = struct{}{}
+	}
+	for _, v := range localValues {
+		seen[v] = struct{}{}
+	}
+	return sortedKeys(seen), nil
+}
+
+// LabelNames implements querierLabelNamer by merging the names reported by
+// both sides. LabelNames isn't part of every storage.Querier implementation
+// at this vintage of the interface (see querierLabelNames), so a side that
+// doesn't support it simply contributes nothing rather than failing the call.
+func (q *splitQuerier) LabelNames() ([]string, error) {
+	remoteNames, err := querierLabelNames(q.remote)
+	if err != nil && q.opts.PartialResponse == PartialResponseFail {
+		return nil, err
+	}
+	localNames, err := querierLabelNames(q.local)
+	if err != nil && q.opts.PartialResponse == PartialResponseFail {
+		return nil, err
+	}
+	seen := map[string]struct{}{}
+	for _, n := range remoteNames {
+		seen[n] = struct{}{}
+	}
+	for _, n := range localNames {
+		seen[n] = struct{}{}
+	}
+	return sortedKeys(seen), nil
+}
+
+// Close cascades to both underlying queriers, returning the first error
+// encountered but always closing both.
+func (q *splitQuerier) Close() error {
+	remoteErr := q.remote.Close()
+	localErr := q.local.Close()
+	if remoteErr != nil {
+		return remoteErr
+	}
+	return localErr
+}
@@ -0,0 +1,102 @@
+// Copyright 2017 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package remote
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/prometheus/prometheus/pkg/labels"
+	"github.com/prometheus/prometheus/storage"
+)
+
+func TestSplitQuerier_BoundaryDedupPrefersLocal(t *testing.T) {
+	lset := labels.Labels{{Name: "__name__", Value: "up"}}
+	remote := &fakeQuerier{selectFn: func(_ ...*labels.Matcher) storage.SeriesSet {
+		return &fakeSeriesSet{series: []storage.Series{fakeSeries{lset: lset, samples: []fakeSample{{t: 100, v: 1}}}}}
+	}}
+	local := &fakeQuerier{selectFn: func(_ ...*labels.Matcher) storage.SeriesSet {
+		return &fakeSeriesSet{series: []storage.Series{fakeSeries{lset: lset, samples: []fakeSample{{t: 100, v: 2}}}}}
+	}}
+
+	q := &splitQuerier{remote: remote, local: local}
+	set := q.Select()
+	if !set.Next() {
+		t.Fatalf("expected a merged series")
+	}
+	it := set.At().Iterator()
+	if !it.Next() {
+		t.Fatalf("expected a sample")
+	}
+	if _, v := it.At(); v != 2 {
+		t.Fatalf("got %v, want 2: local should win the boundary timestamp tie", v)
+	}
+}
+
+func TestSplitQuerier_CloseCascadesToBothSides(t *testing.T) {
+	remote := &fakeQuerier{}
+	local := &fakeQuerier{}
+
+	q := &splitQuerier{remote: remote, local: local}
+	if err := q.Close(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !remote.closed {
+		t.Fatalf("expected remote to be closed")
+	}
+	if !local.closed {
+		t.Fatalf("expected local to be closed")
+	}
+}
+
+func TestSplitQuerier_PartialResponseWarnKeepsOtherSide(t *testing.T) {
+	lset := labels.Labels{{Name: "__name__", Value: "up"}}
+	wantErr := errors.New("remote down")
+	remote := &fakeQuerier{selectFn: func(_ ...*labels.Matcher) storage.SeriesSet {
+		return &fakeSeriesSet{err: wantErr}
+	}}
+	local := &fakeQuerier{selectFn: func(_ ...*labels.Matcher) storage.SeriesSet {
+		return &fakeSeriesSet{series: []storage.Series{fakeSeries{lset: lset, samples: []fakeSample{{t: 1, v: 1}}}}}
+	}}
+
+	q := &splitQuerier{remote: remote, local: local, opts: SplitOptions{PartialResponse: PartialResponseWarn}}
+	set := q.Select()
+	ws, ok := set.(*warnSeriesSet)
+	if !ok {
+		t.Fatalf("expected a *warnSeriesSet, got %T", set)
+	}
+	if warnings := ws.Warnings(); len(warnings) != 1 || warnings[0] != wantErr {
+		t.Fatalf("got warnings %v, want [%v]", warnings, wantErr)
+	}
+	if !set.Next() {
+		t.Fatalf("expected local's series to still come through")
+	}
+}
+
+func TestSplitQuerier_PartialResponseFailAbortsSelect(t *testing.T) {
+	wantErr := errors.New("remote down")
+	remote := &fakeQuerier{selectFn: func(_ ...*labels.Matcher) storage.SeriesSet {
+		return &fakeSeriesSet{err: wantErr}
+	}}
+	local := &fakeQuerier{selectFn: func(_ ...*labels.Matcher) storage.SeriesSet {
+		return &fakeSeriesSet{}
+	}}
+
+	q := &splitQuerier{remote: remote, local: local, opts: SplitOptions{PartialResponse: PartialResponseFail}}
+	set := q.Select()
+	set.Next()
+	if err := set.Err(); err != wantErr {
+		t.Fatalf("got err %v, want %v", err, wantErr)
+	}
+}
@@ -0,0 +1,60 @@
+// Copyright 2017 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package remote
+
+import (
+	"context"
+
+	"github.com/prometheus/prometheus/prompb"
+	"github.com/prometheus/prometheus/storage"
+)
+
+// QueryableClientOptions configures QueryableClientWithOptions.
+type QueryableClientOptions struct {
+	// Streaming selects StreamingSelect as the read path instead of the
+	// default, which buffers and sorts the whole response before Select
+	// returns. Set this for large range queries where holding the fully
+	// decoded response and its sorted copy in memory at once is the
+	// dominant cost.
+	Streaming bool
+}
+
+// QueryableClientWithOptions is QueryableClient with explicit control over
+// the read path. QueryableClient(c) is equivalent to
+// QueryableClientWithOptions(c, QueryableClientOptions{}).
+func QueryableClientWithOptions(c *Client, opts QueryableClientOptions) storage.Queryable {
+	return storage.QuerierFunc(func(ctx context.Context, mint, maxt int64) (storage.Querier, error) {
+		return &querier{
+			ctx:       ctx,
+			mint:      mint,
+			maxt:      maxt,
+			client:    c,
+			streaming: opts.Streaming,
+		}, nil
+	})
+}
+
+// StreamingSelect issues a chunked remote read request against c and
+// returns a storage.SeriesSet that decodes the STREAMED_XOR_CHUNKS response
+// one frame at a time as Next() is called, instead of buffering the whole
+// response into memory first. Series come back in whatever order the
+// endpoint framed them in -- callers that need sorted input (e.g. a merging
+// querier) shouldn't set QueryableClientOptions.Streaming.
+func StreamingSelect(ctx context.Context, c *Client, query *prompb.Query) (storage.SeriesSet, error) {
+	body, err := c.ReadChunked(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	return newChunkedSeriesSet(body), nil
+}
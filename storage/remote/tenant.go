@@ -0,0 +1,163 @@
+// Copyright 2017 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package remote
+
+import (
+	"context"
+	"errors"
+
+	"github.com/prometheus/prometheus/pkg/labels"
+	"github.com/prometheus/prometheus/storage"
+)
+
+// errNoTenant is returned by a TenantQueryableHandler's Querier when the
+// request context carries no tenant id to scope the query to.
+var errNoTenant = errors.New("remote: no tenant in context")
+
+// tenantContextKey is the context key TenantConfig looks under by default
+// for the caller's tenant identifier.
+type tenantContextKey struct{}
+
+// TenantContext returns a copy of ctx carrying tenant as the request's
+// tenant identifier, for handlers that sit in front of a
+// TenantQueryableHandler-wrapped storage.Queryable.
+func TenantContext(ctx context.Context, tenant string) context.Context {
+	return context.WithValue(ctx, tenantContextKey{}, tenant)
+}
+
+// TenantFromContext returns the tenant id stashed by TenantContext, and
+// whether one was present.
+func TenantFromContext(ctx context.Context) (string, bool) {
+	t, ok := ctx.Value(tenantContextKey{}).(string)
+	return t, ok
+}
+
+// readOptionsContextKey is the context key ReadOptions are stashed under.
+// Keeping this out of Client.Read's parameter list means the exported
+// signature every existing caller uses, Read(ctx, query), doesn't change.
+type readOptionsContextKey struct{}
+
+// ReadOptions are per-call options for a remote read request that don't
+// belong in the prompb.Query itself, such as outbound HTTP headers.
+// Client.Read and Client.ReadChunked both look up the ReadOptions stashed by
+// ContextWithReadOptions on the context they're given and apply Headers to the outbound request
+// themselves, so there is nothing else for a caller to wire up.
+type ReadOptions struct {
+	// Headers are added to the outbound HTTP request, e.g. {"THANOS-TENANT":
+	// "team-a"}.
+	Headers map[string]string
+}
+
+// ContextWithReadOptions returns a copy of ctx carrying opts for Client.Read
+// / Client.ReadChunked to apply to the outbound request.
+func ContextWithReadOptions(ctx context.Context, opts ReadOptions) context.Context {
+	return context.WithValue(ctx, readOptionsContextKey{}, opts)
+}
+
+// ReadOptionsFromContext returns the ReadOptions stashed by
+// ContextWithReadOptions, and whether any were present.
+func ReadOptionsFromContext(ctx context.Context) (ReadOptions, bool) {
+	opts, ok := ctx.Value(readOptionsContextKey{}).(ReadOptions)
+	return opts, ok
+}
+
+// TenantConfig configures TenantQueryableHandler.
+type TenantConfig struct {
+	// Header is the outbound HTTP header used to carry the tenant id to the
+	// remote read endpoint, e.g. "THANOS-TENANT".
+	Header string
+	// Label is the label added as an equality matcher to enforce isolation
+	// on the storage side, and checked against every series the remote
+	// endpoint returns.
+	Label string
+	// TenantFromContext extracts the tenant id for the current request.
+	// Defaults to TenantFromContext if nil.
+	TenantFromContext func(ctx context.Context) (string, bool)
+}
+
+func (cfg TenantConfig) tenantFromContext(ctx context.Context) (string, bool) {
+	if cfg.TenantFromContext != nil {
+		return cfg.TenantFromContext(ctx)
+	}
+	return TenantFromContext(ctx)
+}
+
+// TenantQueryableHandler returns a storage.Queryable which creates a
+// tenantQuerier, in the same style as ExternablLabelsHandler and
+// RequiredLabelsFilter.
+func TenantQueryableHandler(next storage.Queryable, cfg TenantConfig) storage.Queryable {
+	return storage.QuerierFunc(func(ctx context.Context, mint, maxt int64) (storage.Querier, error) {
+		tenant, ok := cfg.tenantFromContext(ctx)
+		if !ok {
+			return nil, errNoTenant
+		}
+		if cfg.Header != "" {
+			ctx = ContextWithReadOptions(ctx, ReadOptions{Headers: map[string]string{cfg.Header: tenant}})
+		}
+		q, err := next.Querier(ctx, mint, maxt)
+		if err != nil {
+			return nil, err
+		}
+		return &tenantQuerier{Querier: q, cfg: cfg, tenant: tenant}, nil
+	})
+}
+
+// tenantQuerier wraps a storage.Querier, scoping every Select() to the
+// request's tenant on the storage side and verifying the remote endpoint
+// didn't leak another tenant's series back.
+type tenantQuerier struct {
+	storage.Querier
+
+	cfg    TenantConfig
+	tenant string
+}
+
+// Select adds an equality matcher for cfg.Label pinned to the request's
+// tenant before calling the wrapped storage.Queryable, and drops any
+// returned series whose cfg.Label doesn't match -- a defense against a
+// misconfigured or buggy remote endpoint leaking another tenant's data.
+func (q *tenantQuerier) Select(matchers ...*labels.Matcher) storage.SeriesSet {
+	m, err := labels.NewMatcher(labels.MatchEqual, q.cfg.Label, q.tenant)
+	if err != nil {
+		return errSeriesSet{err: err}
+	}
+	set := q.Querier.Select(append(matchers, m)...)
+	return &tenantSeriesSetFilter{SeriesSet: set, label: q.cfg.Label, tenant: q.tenant}
+}
+
+// tenantSeriesSetFilter drops series that don't carry the expected tenant
+// label, rather than trusting the remote endpoint enforced isolation itself.
+type tenantSeriesSetFilter struct {
+	storage.SeriesSet
+
+	label, tenant string
+}
+
+func (f *tenantSeriesSetFilter) Next() bool {
+	for f.SeriesSet.Next() {
+		if f.seriesInTenant(f.SeriesSet.At()) {
+			return true
+		}
+	}
+	return false
+}
+
+func (f *tenantSeriesSetFilter) seriesInTenant(s storage.Series) bool {
+	for _, l := range s.Labels() {
+		if l.Name == f.label {
+			return l.Value == f.tenant
+		}
+	}
+	return false
+}
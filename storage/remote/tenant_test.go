@@ -0,0 +1,71 @@
+// Copyright 2017 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package remote
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gogo/protobuf/proto"
+	"github.com/golang/snappy"
+	"github.com/prometheus/prometheus/pkg/labels"
+	"github.com/prometheus/prometheus/prompb"
+	"github.com/prometheus/prometheus/storage"
+)
+
+func TestTenantSeriesSetFilter_DropsCrossTenantSeries(t *testing.T) {
+	mine := fakeSeries{lset: labels.Labels{{Name: "__name__", Value: "up"}, {Name: "tenant", Value: "a"}}}
+	theirs := fakeSeries{lset: labels.Labels{{Name: "__name__", Value: "up"}, {Name: "tenant", Value: "b"}}}
+
+	f := &tenantSeriesSetFilter{
+		SeriesSet: &fakeSeriesSet{series: []storage.Series{theirs, mine}},
+		label:     "tenant",
+		tenant:    "a",
+	}
+
+	if !f.Next() {
+		t.Fatalf("expected to find the in-tenant series")
+	}
+	if got := testLabelValue(f.At().Labels(), "tenant"); got != "a" {
+		t.Fatalf("got tenant label %q, want %q: the cross-tenant series should have been dropped", got, "a")
+	}
+	if f.Next() {
+		t.Fatalf("expected no further series once the cross-tenant one was dropped")
+	}
+}
+
+func TestClientRead_AppliesHeaderFromReadOptions(t *testing.T) {
+	var gotHeader string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotHeader = r.Header.Get("THANOS-TENANT")
+		data, _ := proto.Marshal(&prompb.ReadResponse{Results: []*prompb.QueryResult{{}}})
+		w.Write(snappy.Encode(nil, data))
+	}))
+	defer srv.Close()
+
+	c, err := NewClient(ClientConfig{URL: srv.URL})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	ctx := ContextWithReadOptions(context.Background(), ReadOptions{Headers: map[string]string{"THANOS-TENANT": "team-a"}})
+	if _, err := c.Read(ctx, &prompb.Query{}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if gotHeader != "team-a" {
+		t.Fatalf("got THANOS-TENANT header %q, want %q: Client.Read should apply ReadOptions from the context itself", gotHeader, "team-a")
+	}
+}